@@ -0,0 +1,197 @@
+package data
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//MergeStrategy controls how Merge resolves a key present in both maps.
+type MergeStrategy int
+
+const (
+	//Overwrite replaces dst's value with src's value.
+	Overwrite MergeStrategy = iota
+	//KeepExisting leaves dst's value untouched.
+	KeepExisting
+	//DeepMerge merges nested maps recursively, falling back to Overwrite
+	//whenever either side isn't a map.
+	DeepMerge
+)
+
+//splitFlatKey splits a flattened key on sep, treating a backslash-escaped
+//separator (or backslash) as a literal character rather than a split point.
+func splitFlatKey(key, sep string) []string {
+	if sep == "" {
+		return []string{key}
+	}
+
+	parts := []string{}
+	var cur strings.Builder
+	for i := 0; i < len(key); {
+		if key[i] == '\\' && i+1 < len(key) {
+			cur.WriteByte(key[i+1])
+			i += 2
+			continue
+		}
+		if strings.HasPrefix(key[i:], sep) {
+			parts = append(parts, cur.String())
+			cur.Reset()
+			i += len(sep)
+			continue
+		}
+		cur.WriteByte(key[i])
+		i++
+	}
+	parts = append(parts, cur.String())
+	return parts
+}
+
+//insert places value at the nested path described by segments, creating
+//intermediate maps as needed. A segment that already holds a scalar value
+//conflicts with the need to descend further; it is overwritten with a fresh
+//map and the conflict is logged, mirroring how flatMap logs ignorable edge
+//cases instead of failing.
+func insert(dst map[string]interface{}, segments []string, value interface{}) {
+	cur := dst
+	for i, seg := range segments {
+		if i == len(segments)-1 {
+			cur[seg] = value
+			return
+		}
+
+		next, ok := cur[seg]
+		if !ok {
+			nm := make(map[string]interface{})
+			cur[seg] = nm
+			cur = nm
+			continue
+		}
+
+		nm, ok := next.(map[string]interface{})
+		if !ok {
+			log.Printf("Unflatten: key %q conflicts with existing scalar value. overwritten", strings.Join(segments[:i+1], "."))
+			nm = make(map[string]interface{})
+			cur[seg] = nm
+		}
+		cur = nm
+	}
+}
+
+//allNumericKeys returns true if m is non-empty and every key parses as a
+//non-negative integer.
+func allNumericKeys(m map[string]interface{}) bool {
+	if len(m) == 0 {
+		return false
+	}
+	for k := range m {
+		idx, err := strconv.Atoi(k)
+		if err != nil || idx < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+//mapToSlice converts a map with purely numeric keys into a slice, placing
+//each value at its parsed index and leaving gaps as nil.
+func mapToSlice(m map[string]interface{}) []interface{} {
+	maxIdx := -1
+	indexed := make(map[int]interface{}, len(m))
+	for k, v := range m {
+		idx, _ := strconv.Atoi(k)
+		indexed[idx] = v
+		if idx > maxIdx {
+			maxIdx = idx
+		}
+	}
+
+	slice := make([]interface{}, maxIdx+1)
+	for idx, v := range indexed {
+		slice[idx] = v
+	}
+	return slice
+}
+
+//normalizeNumericChildren walks m recursively, replacing any nested map
+//whose keys are all numeric (e.g. "0", "1", ...) with a slice. Maps with a
+//mix of numeric and non-numeric keys are left as maps.
+func normalizeNumericChildren(m map[string]interface{}) {
+	for k, v := range m {
+		cm, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		normalizeNumericChildren(cm)
+		if allNumericKeys(cm) {
+			m[k] = mapToSlice(cm)
+		}
+	}
+}
+
+//Unflatten rebuilds the nested structure produced by FlatMap. Keys like
+//"users.0.name" produce a []interface{} slice for "users" rather than a map
+//with string keys "0", "1", etc. A sep occurring inside a key must be
+//escaped with a backslash (e.g. "a\\.b.c") to be treated as literal content.
+func Unflatten(src map[string]interface{}, sep string) map[string]interface{} {
+	//Split every key up front and insert shortest paths first, so
+	//conflict resolution in insert() is deterministic instead of
+	//depending on Go's randomized map iteration order: a prefix key
+	//(e.g. "a") is always applied before its descendants (e.g. "a.b"),
+	//so the descendant consistently wins the conflict.
+	segments := make(map[string][]string, len(src))
+	keys := make([]string, 0, len(src))
+	for key := range src {
+		keys = append(keys, key)
+		segments[key] = splitFlatKey(key, sep)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		si, sj := segments[keys[i]], segments[keys[j]]
+		if len(si) != len(sj) {
+			return len(si) < len(sj)
+		}
+		return keys[i] < keys[j]
+	})
+
+	dst := make(map[string]interface{})
+	for _, key := range keys {
+		insert(dst, segments[key], src[key])
+	}
+
+	normalizeNumericChildren(dst)
+	return dst
+}
+
+//Merge layers src into dst according to strategy and returns dst, so
+//configuration from multiple flattened sources (env vars, JSON files, ...)
+//can be combined.
+func Merge(dst, src map[string]interface{}, strategy MergeStrategy) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{})
+	}
+
+	for k, sv := range src {
+		dv, exists := dst[k]
+		if !exists {
+			dst[k] = sv
+			continue
+		}
+
+		switch strategy {
+		case KeepExisting:
+			//dst already has a value for k, leave it untouched.
+		case DeepMerge:
+			dm, dok := dv.(map[string]interface{})
+			sm, sok := sv.(map[string]interface{})
+			if dok && sok {
+				dst[k] = Merge(dm, sm, strategy)
+			} else {
+				dst[k] = sv
+			}
+		default: //Overwrite
+			dst[k] = sv
+		}
+	}
+	return dst
+}
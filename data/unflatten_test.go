@@ -0,0 +1,132 @@
+package data
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestUnflatten(t *testing.T) {
+	flat := map[string]interface{}{
+		"localhost.tag":   "dev_latest",
+		"localhost.vhost": "localhost.com",
+		"release.DB.host": "localhost",
+		"release.DB.port": "5432",
+		"users.0.name":    "alice",
+		"users.1.name":    "bob",
+		"other":           123,
+	}
+
+	m := Unflatten(flat, ".")
+	fmt.Printf("%#v\n", m)
+
+	localhost, ok := m["localhost"].(map[string]interface{})
+	if !ok || localhost["tag"] != "dev_latest" || localhost["vhost"] != "localhost.com" {
+		t.Fatalf("unexpected localhost entry: %#v", m["localhost"])
+	}
+
+	release, ok := m["release"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected release to be a map: %#v", m["release"])
+	}
+	db, ok := release["DB"].(map[string]interface{})
+	if !ok || db["host"] != "localhost" || db["port"] != "5432" {
+		t.Fatalf("unexpected release.DB entry: %#v", release["DB"])
+	}
+
+	users, ok := m["users"].([]interface{})
+	if !ok || len(users) != 2 {
+		t.Fatalf("expected users to be a 2-element slice: %#v", m["users"])
+	}
+	if u0, ok := users[0].(map[string]interface{}); !ok || u0["name"] != "alice" {
+		t.Fatalf("unexpected users[0]: %#v", users[0])
+	}
+	if u1, ok := users[1].(map[string]interface{}); !ok || u1["name"] != "bob" {
+		t.Fatalf("unexpected users[1]: %#v", users[1])
+	}
+
+	if m["other"] != 123 {
+		t.Fatalf("unexpected other entry: %#v", m["other"])
+	}
+}
+
+func TestUnflattenMixedSiblingsFallBackToMap(t *testing.T) {
+	flat := map[string]interface{}{
+		"items.0":    "first",
+		"items.name": "not-an-index",
+	}
+
+	m := Unflatten(flat, ".")
+	items, ok := m["items"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected items to stay a map when siblings are mixed: %#v", m["items"])
+	}
+	if items["0"] != "first" || items["name"] != "not-an-index" {
+		t.Fatalf("unexpected items entry: %#v", items)
+	}
+}
+
+func TestUnflattenEscapedSeparator(t *testing.T) {
+	flat := map[string]interface{}{
+		`a\.b.c`: "value",
+	}
+
+	m := Unflatten(flat, ".")
+	ab, ok := m["a.b"].(map[string]interface{})
+	if !ok || ab["c"] != "value" {
+		t.Fatalf("unexpected unflatten result for escaped separator: %#v", m)
+	}
+}
+
+func TestUnflattenConflictingTypes(t *testing.T) {
+	flat := map[string]interface{}{
+		"a":   "scalar",
+		"a.b": "nested",
+	}
+
+	//resolution must not depend on Go's randomized map iteration order,
+	//so run it a number of times rather than trusting a single pass.
+	for i := 0; i < 50; i++ {
+		m := Unflatten(flat, ".")
+		a, ok := m["a"].(map[string]interface{})
+		if !ok || a["b"] != "nested" {
+			t.Fatalf("expected scalar/map conflict to resolve to nested map: %#v", m["a"])
+		}
+	}
+}
+
+func TestMergeOverwrite(t *testing.T) {
+	dst := map[string]interface{}{"id": "old", "kept": "same"}
+	src := map[string]interface{}{"id": "new"}
+
+	m := Merge(dst, src, Overwrite)
+	if m["id"] != "new" || m["kept"] != "same" {
+		t.Fatalf("unexpected overwrite merge result: %#v", m)
+	}
+}
+
+func TestMergeKeepExisting(t *testing.T) {
+	dst := map[string]interface{}{"id": "old"}
+	src := map[string]interface{}{"id": "new", "added": "value"}
+
+	m := Merge(dst, src, KeepExisting)
+	if m["id"] != "old" || m["added"] != "value" {
+		t.Fatalf("unexpected keep-existing merge result: %#v", m)
+	}
+}
+
+func TestMergeDeep(t *testing.T) {
+	dst := map[string]interface{}{
+		"db": map[string]interface{}{"host": "localhost", "port": "5432"},
+	}
+	src := map[string]interface{}{
+		"db": map[string]interface{}{"port": "6543", "ssl": true},
+	}
+
+	m := Merge(dst, src, DeepMerge)
+	db := m["db"].(map[string]interface{})
+	want := map[string]interface{}{"host": "localhost", "port": "6543", "ssl": true}
+	if !reflect.DeepEqual(db, want) {
+		t.Fatalf("unexpected deep merge result: %#v", db)
+	}
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//quoteEscaper matches the escaping mime/multipart applies to the name and
+//filename parameters of a Content-Disposition header.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+//compressedFilePart is a formPart that compresses a file on-the-fly while
+//streaming it. The source file isn't opened, and the compressing goroutine
+//isn't started, until newPart runs during submit() — matching filePart's
+//convention of deferring os.Open until the part is actually about to be
+//sent, so building (and abandoning) a FormUploader never leaks an open
+//file or a goroutine blocked on an unread pipe.
+type compressedFilePart struct {
+	fieldName string    //name of field in multipart content
+	filePath  string    //absolute path of the source file
+	baseName  string    //base name (may differ from original, deduped)
+	encoding  string    //"gzip" or "flate"
+	reader    io.Reader //compressing reader, opened lazily in newPart
+	mpBegin   []byte    //beginning of the multipart
+}
+
+//AddFilesCompressed adds files as multipart parts that are compressed
+//on-the-fly while streaming, using encoding "gzip" or "flate". Since the
+//compressed size isn't known up front, these parts force the request onto
+//chunked transfer encoding.
+func (fu *formUploader) AddFilesCompressed(fieldName, encoding string, filesPath ...string) error {
+	switch encoding {
+	case "gzip", "flate":
+	default:
+		return fmt.Errorf("formuploader: unsupported compression encoding %q", encoding)
+	}
+
+	for _, filePath := range filesPath {
+		absPath, err := filepath.Abs(filePath)
+		if err != nil {
+			return err
+		}
+		baseName := fu.dedupeBaseName(filepath.Base(absPath))
+
+		cp := &compressedFilePart{
+			fieldName: fieldName,
+			filePath:  absPath,
+			baseName:  baseName,
+			encoding:  encoding,
+		}
+		fu.compressedFiles = append(fu.compressedFiles, cp)
+	}
+	return nil
+}
+
+func (p *compressedFilePart) newPart(buf *bytes.Buffer, mpw *multipart.Writer) (int64, error) {
+	//make sure any previous reader is closed
+	if err := p.close(); err != nil {
+		return 0, err
+	}
+
+	r, err := newCompressingFileReader(p.filePath, p.encoding)
+	if err != nil {
+		return 0, err
+	}
+	p.reader = r
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(p.fieldName), quoteEscaper.Replace(p.baseName)))
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set("Content-Encoding", p.encoding)
+	if _, err := mpw.CreatePart(header); err != nil {
+		return 0, err
+	}
+
+	n := buf.Len()
+	if cap(p.mpBegin) < n {
+		p.mpBegin = make([]byte, n)
+	}
+	nr, err := buf.Read(p.mpBegin)
+	if err != nil {
+		return int64(nr), err
+	}
+	//correctly assign data len
+	p.mpBegin = p.mpBegin[:nr]
+
+	//compressed size is not known up front.
+	return int64(n), nil
+}
+func (p *compressedFilePart) writeTo(chunk []byte, w io.Writer) error {
+	//write multipart begin
+	if err := writeExactly(w, p.mpBegin); err != nil {
+		return err
+	}
+
+	_, err := io.CopyBuffer(w, p.reader, chunk)
+	return err
+}
+func (p *compressedFilePart) close() error {
+	if rc, ok := p.reader.(io.Closer); ok {
+		p.reader = nil
+		return rc.Close()
+	}
+	p.reader = nil
+	return nil
+}
+
+//newCompressingFileReader opens path and returns a reader that streams its
+//content through a gzip or flate compressor over an io.Pipe, so memory usage
+//stays bounded (a copy buffer plus the compressor's state) regardless of
+//file size.
+func newCompressingFileReader(path, encoding string) (io.Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	var cw io.WriteCloser
+	switch encoding {
+	case "gzip":
+		cw = gzip.NewWriter(pw)
+	case "flate":
+		cw, err = flate.NewWriter(pw, flate.DefaultCompression)
+		if err != nil {
+			file.Close()
+			pw.Close()
+			return nil, err
+		}
+	default:
+		file.Close()
+		pw.Close()
+		return nil, fmt.Errorf("formuploader: unsupported compression encoding %q", encoding)
+	}
+
+	go func() {
+		defer file.Close()
+		if _, err := io.Copy(cw, file); err != nil {
+			cw.Close()
+			pw.CloseWithError(err)
+			return
+		}
+		if err := cw.Close(); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.Close()
+	}()
+
+	return pr, nil
+}
@@ -0,0 +1,164 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+const compressedUploadTarget = "/upload-compressed"
+
+//compressedUploadHandler decompresses each file part according to its
+//Content-Encoding header and writes the result alongside the original
+//upload directory, so the test can compare byte equality.
+func compressedUploadHandler(w http.ResponseWriter, r *http.Request) {
+	const maxMemory = 1024 * 1024
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, fh := range r.MultipartForm.File[fileField] {
+		file, err := fh.Open()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var src io.Reader
+		switch fh.Header.Get("Content-Encoding") {
+		case "gzip":
+			gr, err := gzip.NewReader(file)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer gr.Close()
+			src = gr
+		case "flate":
+			fr := flate.NewReader(file)
+			defer fr.Close()
+			src = fr
+		default:
+			src = file
+		}
+
+		dstFile, err := os.Create(uploadDir + fh.Filename)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer dstFile.Close()
+
+		if _, err := io.Copy(dstFile, src); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		file.Close()
+	}
+
+	w.Write([]byte("ok\n"))
+}
+
+func testCompressedUpload(t *testing.T, encoding string) {
+	fu := NewFormUploader()
+	if err := fu.AddFilesCompressed(fileField, encoding, dataDir+"file01.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	url := serverHost + serverPort + compressedUploadTarget
+	resp, err := fu.Post(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	want, err := ioutil.ReadFile(dataDir + "file01.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(uploadDir + "file01.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Fatalf("decompressed upload (%s) doesn't match original content", encoding)
+	}
+}
+
+func TestAddFilesCompressedGzip(t *testing.T) {
+	testCompressedUpload(t, "gzip")
+}
+
+func TestAddFilesCompressedFlate(t *testing.T) {
+	testCompressedUpload(t, "flate")
+}
+
+func TestAddFilesCompressedDedupesCollidingBaseNames(t *testing.T) {
+	fu := NewFormUploader()
+	if err := fu.AddFilesCompressed(fileField, "gzip", dataDir+"file01.txt", dataDir+"conflict/file01.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	url := serverHost + serverPort + compressedUploadTarget
+	resp, err := fu.Post(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	want1, err := ioutil.ReadFile(dataDir + "file01.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want2, err := ioutil.ReadFile(dataDir + "conflict/file01.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got1, err := ioutil.ReadFile(uploadDir + "file01.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got2, err := ioutil.ReadFile(uploadDir + "_001_file01.txt")
+	if err != nil {
+		t.Fatalf("expected a deduped filename _001_file01.txt, got error: %v", err)
+	}
+
+	if !bytes.Equal(want1, got1) {
+		t.Fatal("first file01.txt doesn't match original content")
+	}
+	if !bytes.Equal(want2, got2) {
+		t.Fatal("second, deduped file01.txt doesn't match its original content")
+	}
+}
+
+func TestAddFilesCompressedDeferredUntilSubmit(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	fu := NewFormUploader()
+	if err := fu.AddFilesCompressed(fileField, "gzip", dataDir+"file01.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	//give any eagerly-started compressing goroutine a chance to spin up
+	time.Sleep(50 * time.Millisecond)
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("AddFilesCompressed started %d goroutine(s) before submit; the source file must stay unopened and the compressing goroutine unstarted until Post/Put runs", after-before)
+	}
+}
+
+func TestAddFilesCompressedUnsupported(t *testing.T) {
+	fu := NewFormUploader()
+	if err := fu.AddFilesCompressed(fileField, "br", dataDir+"file01.txt"); err == nil {
+		t.Fatal("expected error for unsupported encoding")
+	}
+}
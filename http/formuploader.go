@@ -2,12 +2,15 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 )
 
 //FormUploader represents HTTP multipart form submission.
@@ -17,12 +20,23 @@ type FormUploader interface {
 	Fields(name string) []string
 	AddFiles(fieldName string, filesPath ...string) error
 	Files() []string
+	AddReader(fieldName, fileName string, r io.Reader, size int64) error
+	AddReaderPart(fieldName, fileName string, r io.Reader, size int64, header textproto.MIMEHeader) error
+	AddFilesCompressed(fieldName, encoding string, filesPath ...string) error
 	SetChunkSize(size int64) FormUploader
 	ChunkSize() int64
+	SetProgressFunc(fn func(bytesSent, totalBytes int64)) FormUploader
+	SetResumable(on bool) FormUploader
+	Resumable() bool
+	SetMaxRetries(n int) FormUploader
+	MaxRetries() int
+	ResumeUpload(sessionURL string) (*http.Response, error)
 	Post(targetURL string) (*http.Response, error)
 	Put(targetURL string) (*http.Response, error)
 	PostWith(client *http.Client, targetURL string) (*http.Response, error)
 	PutWith(client *http.Client, targetURL string) (*http.Response, error)
+	PostContext(ctx context.Context, targetURL string) (*http.Response, error)
+	PutContext(ctx context.Context, targetURL string) (*http.Response, error)
 }
 
 type formPart interface {
@@ -46,19 +60,52 @@ type filePart struct {
 	mpBegin   []byte   //Beginning of the multipart
 }
 
+type readerPart struct {
+	reader    io.Reader            //underlying content, may also implement io.Closer
+	size      int64                //content size, -1 if unknown
+	fieldName string               //name of field in multipart content
+	fileName  string               //file name reported to the server
+	header    textproto.MIMEHeader //custom part header, nil to use default form-file header
+	mpBegin   []byte               //beginning of the multipart
+}
+
 type endPart struct {
 	mpData []byte //multipart data
 }
 
 type formUploader struct {
-	chunkSize int64
-	fields    []*fieldPart
-	files     []*filePart
+	chunkSize       int64
+	fields          []*fieldPart
+	files           []*filePart
+	readers         []*readerPart
+	compressedFiles []*compressedFilePart
+	progressFunc    func(bytesSent, totalBytes int64)
+	resumable       bool
+	maxRetries      int
+	sessionURL      string
+	fileBaseNames   map[string]bool
+}
+
+//countingWriter wraps an io.Writer, reporting cumulative bytes written
+//through progress after every successful Write.
+type countingWriter struct {
+	w        io.Writer
+	sent     *int64
+	total    int64
+	progress func(bytesSent, totalBytes int64)
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	if n > 0 && cw.progress != nil {
+		cw.progress(atomic.AddInt64(cw.sent, int64(n)), cw.total)
+	}
+	return n, err
 }
 
 //NewFormUploader creates form uploder instance.
 func NewFormUploader() FormUploader {
-	return &formUploader{chunkSize: 1024 * 10}
+	return &formUploader{chunkSize: 1024 * 10, maxRetries: 5}
 }
 
 //Write all the data to writer.
@@ -166,6 +213,52 @@ func (p *filePart) close() error {
 	return nil
 }
 
+func (p *readerPart) newPart(buf *bytes.Buffer, mpw *multipart.Writer) (int64, error) {
+	//Create file part (the content is not writen)
+	var err error
+	if p.header != nil {
+		_, err = mpw.CreatePart(p.header)
+	} else {
+		_, err = mpw.CreateFormFile(p.fieldName, p.fileName)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	n := buf.Len()
+	if cap(p.mpBegin) < n {
+		p.mpBegin = make([]byte, n)
+	}
+	nr, err := buf.Read(p.mpBegin)
+	if err != nil {
+		return int64(nr), err
+	}
+	//correctly assign data len
+	p.mpBegin = p.mpBegin[:nr]
+
+	//size is unknown, caller must switch to chunked transfer encoding
+	if p.size < 0 {
+		return int64(n), nil
+	}
+
+	return int64(n) + p.size, nil
+}
+func (p *readerPart) writeTo(chunk []byte, w io.Writer) error {
+	//write multipart begin
+	if err := writeExactly(w, p.mpBegin); err != nil {
+		return err
+	}
+
+	_, err := io.CopyBuffer(w, p.reader, chunk)
+	return err
+}
+func (p *readerPart) close() error {
+	if rc, ok := p.reader.(io.Closer); ok {
+		return rc.Close()
+	}
+	return nil
+}
+
 func (p *endPart) newPart(buf *bytes.Buffer, mpw *multipart.Writer) (int64, error) {
 	//end boundary
 	if err := mpw.Close(); err != nil {
@@ -217,23 +310,34 @@ func (fu *formUploader) Fields(name string) []string {
 	}
 	return fields
 }
+//dedupeBaseName returns baseName, or a "_NNN_"-prefixed variant if baseName
+//was already used by a previous AddFiles/AddFilesCompressed call, so files
+//from different directories that share a name don't collide under the same
+//multipart field name.
+func (fu *formUploader) dedupeBaseName(baseName string) string {
+	if fu.fileBaseNames == nil {
+		fu.fileBaseNames = make(map[string]bool)
+	}
+
+	name := baseName
+	for n := 1; n < 1000; n++ {
+		//name doesn't exist (no name conflict)
+		if _, ok := fu.fileBaseNames[name]; !ok {
+			fu.fileBaseNames[name] = true
+			break
+		}
+		name = fmt.Sprintf("_%03d_%s", n, baseName)
+	}
+	return name
+}
+
 func (fu *formUploader) AddFiles(fieldName string, filesPath ...string) error {
-	baseNames := make(map[string]bool)
 	for _, filePath := range filesPath {
 		filePath, err := filepath.Abs(filePath)
 		if err != nil {
 			return err
 		}
-		baseName := filepath.Base(filePath)
-		name := baseName
-		for n := 1; n < 1000; n++ {
-			//name doesn't exist (no name conflict)
-			if _, ok := baseNames[name]; !ok {
-				baseNames[name] = true
-				break
-			}
-			name = fmt.Sprintf("_%03d_%s", n, baseName)
-		}
+		name := fu.dedupeBaseName(filepath.Base(filePath))
 
 		fp := &filePart{
 			fieldName: fieldName,
@@ -251,6 +355,20 @@ func (fu *formUploader) Files() []string {
 	}
 	return filePaths
 }
+func (fu *formUploader) AddReader(fieldName, fileName string, r io.Reader, size int64) error {
+	return fu.AddReaderPart(fieldName, fileName, r, size, nil)
+}
+func (fu *formUploader) AddReaderPart(fieldName, fileName string, r io.Reader, size int64, header textproto.MIMEHeader) error {
+	rp := &readerPart{
+		fieldName: fieldName,
+		fileName:  fileName,
+		reader:    r,
+		size:      size,
+		header:    header,
+	}
+	fu.readers = append(fu.readers, rp)
+	return nil
+}
 func (fu *formUploader) SetChunkSize(size int64) FormUploader {
 	fu.chunkSize = size
 	return fu
@@ -258,23 +376,68 @@ func (fu *formUploader) SetChunkSize(size int64) FormUploader {
 func (fu *formUploader) ChunkSize() int64 {
 	return fu.chunkSize
 }
+
+//SetProgressFunc registers fn to be called from the upload writer goroutine
+//after every chunk is written, for both field and file parts. fn must be
+//goroutine-safe; the caller is responsible for keeping it non-blocking since
+//it is invoked synchronously on the hot write path. In resumable mode fn is
+//instead called from the goroutine running the upload, once after every
+//chunk the server confirms.
+func (fu *formUploader) SetProgressFunc(fn func(bytesSent, totalBytes int64)) FormUploader {
+	fu.progressFunc = fn
+	return fu
+}
+
+//SetResumable enables the tus.io resumable upload protocol: instead of a
+//single multipart POST/PUT, the upload is split into PATCH chunks of
+//ChunkSize that can be resumed with ResumeUpload after a transient failure
+//or process restart.
+func (fu *formUploader) SetResumable(on bool) FormUploader {
+	fu.resumable = on
+	return fu
+}
+func (fu *formUploader) Resumable() bool {
+	return fu.resumable
+}
+
+//SetMaxRetries sets how many times a PATCH chunk is retried, with
+//exponential backoff, before a resumable upload gives up.
+func (fu *formUploader) SetMaxRetries(n int) FormUploader {
+	fu.maxRetries = n
+	return fu
+}
+func (fu *formUploader) MaxRetries() int {
+	return fu.maxRetries
+}
 func (fu *formUploader) Post(targetURL string) (*http.Response, error) {
-	return fu.submit(http.DefaultClient, targetURL, "POST")
+	return fu.submit(context.Background(), http.DefaultClient, targetURL, "POST")
 }
 func (fu *formUploader) Put(targetURL string) (*http.Response, error) {
-	return fu.submit(http.DefaultClient, targetURL, "PUT")
+	return fu.submit(context.Background(), http.DefaultClient, targetURL, "PUT")
 }
 func (fu *formUploader) PostWith(client *http.Client, targetURL string) (*http.Response, error) {
-	return fu.submit(client, targetURL, "POST")
+	return fu.submit(context.Background(), client, targetURL, "POST")
 }
 func (fu *formUploader) PutWith(client *http.Client, targetURL string) (*http.Response, error) {
-	return fu.submit(client, targetURL, "PUT")
+	return fu.submit(context.Background(), client, targetURL, "PUT")
+}
+func (fu *formUploader) PostContext(ctx context.Context, targetURL string) (*http.Response, error) {
+	return fu.submit(ctx, http.DefaultClient, targetURL, "POST")
 }
-func (fu *formUploader) submit(client *http.Client, targetURL, method string) (*http.Response, error) {
+func (fu *formUploader) PutContext(ctx context.Context, targetURL string) (*http.Response, error) {
+	return fu.submit(ctx, http.DefaultClient, targetURL, "PUT")
+}
+func (fu *formUploader) submit(ctx context.Context, client *http.Client, targetURL, method string) (*http.Response, error) {
+	//resumable mode uses the tus.io protocol (POST to create a session,
+	//then PATCH to stream chunks) regardless of method.
+	if fu.resumable {
+		return fu.resumableSubmit(ctx, client, targetURL)
+	}
+
 	buf := &bytes.Buffer{}
 	mpw := multipart.NewWriter(buf)
 
-	//List of form parts: fields, files, end
+	//List of form parts: fields, files, readers, compressed files, end
 	parts := []formPart{}
 	for _, p := range fu.fields {
 		parts = append(parts, p)
@@ -282,8 +445,25 @@ func (fu *formUploader) submit(client *http.Client, targetURL, method string) (*
 	for _, p := range fu.files {
 		parts = append(parts, p)
 	}
+	for _, p := range fu.readers {
+		parts = append(parts, p)
+	}
+	for _, p := range fu.compressedFiles {
+		parts = append(parts, p)
+	}
 	parts = append(parts, &endPart{})
 
+	//unknown size reader part forces chunked transfer encoding,
+	//since total content length can't be determined up front. Compressed
+	//files are always unknown size for the same reason.
+	unknownSize := len(fu.compressedFiles) > 0
+	for _, p := range fu.readers {
+		if p.size < 0 {
+			unknownSize = true
+			break
+		}
+	}
+
 	//create parts and calculate size.
 	totalContentLen := int64(0)
 	for _, p := range parts {
@@ -308,6 +488,13 @@ func (fu *formUploader) submit(client *http.Client, targetURL, method string) (*
 	reader, writer := io.Pipe()
 	defer reader.Close()
 
+	//wrap the pipe writer to report progress, if requested.
+	var dst io.Writer = writer
+	if fu.progressFunc != nil {
+		sentBytes := int64(0)
+		dst = &countingWriter{w: writer, sent: &sentBytes, total: totalContentLen, progress: fu.progressFunc}
+	}
+
 	//Write parts content
 	var routineErr error
 	go func() {
@@ -316,7 +503,7 @@ func (fu *formUploader) submit(client *http.Client, targetURL, method string) (*
 		//allocate buffer for reading file.
 		chunk := make([]byte, fu.chunkSize)
 		for _, p := range parts {
-			if err := p.writeTo(chunk, writer); err != nil {
+			if err := p.writeTo(chunk, dst); err != nil {
 				routineErr = err
 				break
 			}
@@ -324,12 +511,14 @@ func (fu *formUploader) submit(client *http.Client, targetURL, method string) (*
 	}()
 
 	//construct HTTP client Request with rd
-	req, err := http.NewRequest(method, targetURL, reader)
+	req, err := http.NewRequestWithContext(ctx, method, targetURL, reader)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", mpw.FormDataContentType())
-	req.ContentLength = totalContentLen
+	if !unknownSize {
+		req.ContentLength = totalContentLen
+	}
 
 	//process request
 	resp, err := client.Do(req)
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -83,6 +84,10 @@ func TestMain(m *testing.M) {
 func setup() *http.Server {
 	mux := http.NewServeMux()
 	mux.HandleFunc(uploadTarget, uploadHandler)
+	mux.HandleFunc(resumableTarget, resumableHandler)
+	mux.HandleFunc(resumableFlakyTarget, resumableFlakyHandler)
+	mux.HandleFunc(resumableConflictTarget, resumableConflictHandler)
+	mux.HandleFunc(compressedUploadTarget, compressedUploadHandler)
 
 	//DON'T forget to allow connection to PORT
 	//when the PC has firewall.
@@ -200,6 +205,67 @@ func TestPutFilesWithFields(t *testing.T) {
 	doSubmit(fu.Put, true, t)
 }
 
+func TestReaderWithKnownSize(t *testing.T) {
+	fu := NewFormUploader()
+	fu.AddField("id", "Reader upload")
+
+	content := []byte("content streamed from an in-memory buffer\n")
+	if err := fu.AddReader(fileField, "buffer01.txt", bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+
+	//perform upload
+	doSubmit(fu.Post, true, t)
+}
+
+func TestReaderWithUnknownSize(t *testing.T) {
+	fu := NewFormUploader()
+
+	content := []byte("content streamed without a known size, falls back to chunked encoding\n")
+	r, w := io.Pipe()
+	go func() {
+		defer w.Close()
+		w.Write(content)
+	}()
+	if err := fu.AddReader(fileField, "piped01.txt", r, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	//perform upload
+	doSubmit(fu.Post, true, t)
+}
+
+func TestProgressFunc(t *testing.T) {
+	fu := NewFormUploader()
+	fu.AddField("id", "Progress test")
+	fu.AddFiles(fileField, dataDir+"file01.txt")
+
+	var lastSent, lastTotal int64
+	fu.SetProgressFunc(func(bytesSent, totalBytes int64) {
+		lastSent = bytesSent
+		lastTotal = totalBytes
+	})
+
+	doSubmit(fu.Post, true, t)
+
+	if lastSent == 0 || lastSent != lastTotal {
+		t.Fatalf("progress not fully reported: sent=%d total=%d", lastSent, lastTotal)
+	}
+}
+
+func TestPostContextCancel(t *testing.T) {
+	fu := NewFormUploader()
+	fu.AddFiles(fileField, dataDir+"file01.txt")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	url := serverHost + serverPort + uploadTarget
+	if _, err := fu.PostContext(ctx, url); err == nil {
+		t.Fatal("expected error for cancelled context")
+	}
+}
+
 func TestFileDoesNotExist(t *testing.T) {
 	fu := NewFormUploader()
 	files := []string{
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//GraphQLUploader represents a GraphQL multipart request
+//submission, as described by the GraphQL multipart request spec.
+type GraphQLUploader interface {
+	SetQuery(query string, variables map[string]interface{}) GraphQLUploader
+	AttachFile(variablePath, filePath string) error
+	Post(targetURL string) (*http.Response, error)
+	PostWith(client *http.Client, targetURL string) (*http.Response, error)
+}
+
+type graphqlAttachment struct {
+	variablePath string //dotted path into variables, e.g. variables.input.avatar
+	filePath     string //path of the file to attach
+}
+
+type graphqlUploader struct {
+	query       string
+	variables   map[string]interface{}
+	attachments []graphqlAttachment
+}
+
+//NewGraphQLUploader creates GraphQL multipart uploader instance.
+func NewGraphQLUploader() GraphQLUploader {
+	return &graphqlUploader{}
+}
+
+func (g *graphqlUploader) SetQuery(query string, variables map[string]interface{}) GraphQLUploader {
+	g.query = query
+	g.variables = variables
+	return g
+}
+
+//AttachFile marks the value found at variablePath (e.g. "variables.input.avatar"
+//or "variables.files.0") to be replaced by the given file upon submit. The path
+//must resolve to a null placeholder already present in the variables set via
+//SetQuery, matching the GraphQL multipart request spec convention.
+func (g *graphqlUploader) AttachFile(variablePath, filePath string) error {
+	if err := checkNullPlaceholder(g.variables, variablePath); err != nil {
+		return err
+	}
+
+	g.attachments = append(g.attachments, graphqlAttachment{
+		variablePath: variablePath,
+		filePath:     filePath,
+	})
+	return nil
+}
+
+//checkNullPlaceholder walks a dotted path (e.g. "variables.input.avatar") into
+//root, descending into maps and, for numeric segments, into slices, and
+//returns an error unless the value found at path is nil.
+func checkNullPlaceholder(root map[string]interface{}, path string) error {
+	segments := strings.Split(path, ".")
+	if len(segments) == 0 || segments[0] != "variables" {
+		return fmt.Errorf("graphqluploader: variable path %q must start with \"variables\"", path)
+	}
+
+	var cur interface{} = root
+	for _, seg := range segments[1:] {
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			val, ok := v[seg]
+			if !ok {
+				return fmt.Errorf("graphqluploader: variable path %q: key %q not found", path, seg)
+			}
+			cur = val
+		case []interface{}:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return fmt.Errorf("graphqluploader: variable path %q: invalid index %q", path, seg)
+			}
+			cur = v[idx]
+		default:
+			return fmt.Errorf("graphqluploader: variable path %q: %q is not a map or slice", path, seg)
+		}
+	}
+
+	if cur != nil {
+		return fmt.Errorf("graphqluploader: variable path %q does not resolve to a null placeholder", path)
+	}
+	return nil
+}
+
+//build assembles a fresh FormUploader with the operations, map and file
+//fields set in the order required by the GraphQL multipart request spec.
+//A new FormUploader is built on every call, rather than reused across
+//calls, so retrying Post/PostWith after a failed attempt resends a clean
+//body instead of appending duplicate operations/map/file parts to state
+//left over from the previous attempt.
+func (g *graphqlUploader) build() (FormUploader, error) {
+	operations, err := json.Marshal(struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}{Query: g.query, Variables: g.variables})
+	if err != nil {
+		return nil, err
+	}
+
+	fileMap := make(map[string][]string, len(g.attachments))
+	for i, att := range g.attachments {
+		index := strconv.Itoa(i)
+		fileMap[index] = []string{att.variablePath}
+	}
+	mapJSON, err := json.Marshal(fileMap)
+	if err != nil {
+		return nil, err
+	}
+
+	fu := NewFormUploader()
+	fu.AddField("operations", string(operations))
+	fu.AddField("map", string(mapJSON))
+	for i, att := range g.attachments {
+		if err := fu.AddFiles(strconv.Itoa(i), att.filePath); err != nil {
+			return nil, err
+		}
+	}
+
+	return fu, nil
+}
+
+func (g *graphqlUploader) Post(targetURL string) (*http.Response, error) {
+	return g.PostWith(http.DefaultClient, targetURL)
+}
+func (g *graphqlUploader) PostWith(client *http.Client, targetURL string) (*http.Response, error) {
+	fu, err := g.build()
+	if err != nil {
+		return nil, err
+	}
+	return fu.PostWith(client, targetURL)
+}
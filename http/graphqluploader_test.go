@@ -0,0 +1,96 @@
+package http
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+const graphqlQuery = `
+mutation ($input: AvatarInput!) {
+	updateAvatar(input: $input) { id }
+}
+`
+
+func TestGraphQLAttachFile(t *testing.T) {
+	variables := map[string]interface{}{
+		"input": map[string]interface{}{
+			"avatar": nil,
+		},
+	}
+
+	gu := NewGraphQLUploader()
+	gu.SetQuery(graphqlQuery, variables)
+	if err := gu.AttachFile("variables.input.avatar", dataDir+"image01.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	//perform upload
+	doSubmit(gu.Post, true, t)
+}
+
+func TestGraphQLAttachFileNotNull(t *testing.T) {
+	variables := map[string]interface{}{
+		"input": map[string]interface{}{
+			"avatar": "not-a-placeholder",
+		},
+	}
+
+	gu := NewGraphQLUploader()
+	gu.SetQuery(graphqlQuery, variables)
+	if err := gu.AttachFile("variables.input.avatar", dataDir+"image01.jpg"); err == nil {
+		t.FailNow()
+	}
+}
+
+//TestGraphQLPostRetrySendsCleanBody checks that calling Post a second time
+//on the same GraphQLUploader (e.g. a caller retrying after a transient
+//network error) resends a clean body instead of duplicating the
+//operations/map/file parts built by the first attempt.
+func TestGraphQLPostRetrySendsCleanBody(t *testing.T) {
+	variables := map[string]interface{}{
+		"input": map[string]interface{}{
+			"avatar": nil,
+		},
+	}
+
+	gu := NewGraphQLUploader()
+	gu.SetQuery(graphqlQuery, variables)
+	if err := gu.AttachFile("variables.input.avatar", dataDir+"image01.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	url := serverHost + serverPort + uploadTarget
+	const want = "fields=02, files=01\n"
+	for attempt := 1; attempt <= 2; attempt++ {
+		resp, err := gu.Post(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != want {
+			t.Fatalf("attempt %d: got %q, want %q", attempt, string(body), want)
+		}
+	}
+}
+
+func TestGraphQLAttachFileIndexedPath(t *testing.T) {
+	variables := map[string]interface{}{
+		"files": []interface{}{nil, nil},
+	}
+
+	gu := NewGraphQLUploader()
+	gu.SetQuery(graphqlQuery, variables)
+	if err := gu.AttachFile("variables.files.0", dataDir+"file01.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gu.AttachFile("variables.files.1", dataDir+"file02.pdf"); err != nil {
+		t.Fatal(err)
+	}
+
+	//perform upload
+	doSubmit(gu.Post, true, t)
+}
@@ -0,0 +1,266 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+//tusResumableVersion is the tus.io protocol version this client speaks.
+const tusResumableVersion = "1.0.0"
+
+//openBodyStream starts streaming the multipart body through an io.Pipe,
+//exactly like submit() does for a plain POST/PUT, so a resumable upload
+//never holds more than one chunk in memory regardless of file size. The
+//caller must Close the returned reader once done with it.
+func (fu *formUploader) openBodyStream() (io.ReadCloser, string, int64, error) {
+	buf := &bytes.Buffer{}
+	mpw := multipart.NewWriter(buf)
+
+	parts := []formPart{}
+	for _, p := range fu.fields {
+		parts = append(parts, p)
+	}
+	for _, p := range fu.files {
+		parts = append(parts, p)
+	}
+	for _, p := range fu.readers {
+		parts = append(parts, p)
+	}
+	for _, p := range fu.compressedFiles {
+		parts = append(parts, p)
+	}
+	parts = append(parts, &endPart{})
+
+	totalContentLen := int64(0)
+	for _, p := range parts {
+		n, err := p.newPart(buf, mpw)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		totalContentLen += n
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		defer func() {
+			for _, p := range parts {
+				p.close()
+			}
+		}()
+
+		chunk := make([]byte, fu.chunkSize)
+		for _, p := range parts {
+			if err := p.writeTo(chunk, writer); err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+		}
+		writer.Close()
+	}()
+
+	return reader, mpw.FormDataContentType(), totalContentLen, nil
+}
+
+//resumableSubmit creates a new upload session and streams the body to it.
+func (fu *formUploader) resumableSubmit(ctx context.Context, client *http.Client, targetURL string) (*http.Response, error) {
+	stream, contentType, total, err := fu.openBodyStream()
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	sessionURL, err := fu.createSession(ctx, client, targetURL, total, contentType)
+	if err != nil {
+		return nil, err
+	}
+	fu.sessionURL = sessionURL
+
+	return fu.uploadChunks(ctx, client, sessionURL, stream, total, 0)
+}
+
+//ResumeUpload resumes a previously created upload session, picking up from
+//the offset the server reports it already has, so the upload can continue
+//across process restarts as long as the same content is re-added first.
+func (fu *formUploader) ResumeUpload(sessionURL string) (*http.Response, error) {
+	ctx := context.Background()
+	stream, _, total, err := fu.openBodyStream()
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	offset, err := fu.headOffset(ctx, http.DefaultClient, sessionURL)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		//the body is re-generated from scratch, so replay and discard
+		//whatever the server already has before resuming from there.
+		if _, err := io.CopyN(io.Discard, stream, offset); err != nil {
+			return nil, fmt.Errorf("resumable upload: replaying content up to offset %d: %w", offset, err)
+		}
+	}
+
+	fu.sessionURL = sessionURL
+	return fu.uploadChunks(ctx, http.DefaultClient, sessionURL, stream, total, offset)
+}
+
+//createSession issues the tus.io creation request and returns the absolute
+//session URL taken from the response's Location header.
+func (fu *formUploader) createSession(ctx context.Context, client *http.Client, targetURL string, size int64, contentType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", targetURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Tus-Resumable", tusResumableVersion)
+	req.Header.Set("Upload-Length", strconv.FormatInt(size, 10))
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("resumable upload: server response has no Location header")
+	}
+
+	//resolve a relative Location against targetURL.
+	base, err := url.Parse(targetURL)
+	if err != nil {
+		return location, nil
+	}
+	ref, err := url.Parse(location)
+	if err != nil {
+		return location, nil
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+//headOffset queries the session URL for the number of bytes the server
+//already has, so an interrupted upload can resume past them.
+func (fu *formUploader) headOffset(ctx context.Context, client *http.Client, sessionURL string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", sessionURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Tus-Resumable", tusResumableVersion)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	offset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("resumable upload: invalid Upload-Offset header: %w", err)
+	}
+	return offset, nil
+}
+
+//patchChunk sends a single chunk at offset via PATCH.
+func (fu *formUploader) patchChunk(ctx context.Context, client *http.Client, sessionURL string, offset int64, chunk []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "PATCH", sessionURL, bytes.NewReader(chunk))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Tus-Resumable", tusResumableVersion)
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.ContentLength = int64(len(chunk))
+
+	return client.Do(req)
+}
+
+//uploadChunks reads src in fu.chunkSize pieces and PATCHes each to
+//sessionURL, starting at startOffset, so memory usage stays O(chunkSize)
+//regardless of the total upload size. If fu.progressFunc is set, it's
+//called with the cumulative bytes confirmed by the server after every
+//successful chunk.
+//
+//On a network error or 5xx response for a chunk, it re-reads the current
+//Upload-Offset and retries sending only the unsent portion of that same
+//chunk (already held in memory), backing off exponentially between
+//attempts, up to fu.maxRetries. Any other non-2xx response, or a 2xx whose
+//reported Upload-Offset doesn't match what was just sent, is treated as a
+//hard error rather than silently advancing.
+func (fu *formUploader) uploadChunks(ctx context.Context, client *http.Client, sessionURL string, src io.Reader, total int64, startOffset int64) (*http.Response, error) {
+	offset := startOffset
+	chunkBuf := make([]byte, fu.chunkSize)
+
+	for offset < total {
+		want := fu.chunkSize
+		if remain := total - offset; remain < want {
+			want = remain
+		}
+
+		n, err := io.ReadFull(src, chunkBuf[:want])
+		if err != nil {
+			return nil, fmt.Errorf("resumable upload: reading chunk at offset %d: %w", offset, err)
+		}
+		chunk := chunkBuf[:n]
+		chunkEnd := offset + int64(n)
+		sendFrom := offset
+		retries := 0
+
+		for {
+			resp, err := fu.patchChunk(ctx, client, sessionURL, sendFrom, chunk[sendFrom-offset:])
+			if err != nil || (resp != nil && resp.StatusCode >= http.StatusInternalServerError) {
+				retries++
+				if retries > fu.maxRetries {
+					if resp != nil {
+						resp.Body.Close()
+					}
+					if err != nil {
+						return nil, err
+					}
+					return nil, fmt.Errorf("resumable upload: giving up after %d retries, last status %s", fu.maxRetries, resp.Status)
+				}
+
+				if resp != nil {
+					resp.Body.Close()
+				}
+				time.Sleep(time.Duration(1<<uint(retries)) * 100 * time.Millisecond)
+
+				if current, herr := fu.headOffset(ctx, client, sessionURL); herr == nil && current > sendFrom && current <= chunkEnd {
+					sendFrom = current
+				}
+				continue
+			}
+
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				resp.Body.Close()
+				return nil, fmt.Errorf("resumable upload: unexpected status %s from PATCH", resp.Status)
+			}
+
+			reported, perr := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+			if perr != nil || reported != chunkEnd {
+				resp.Body.Close()
+				return nil, fmt.Errorf("resumable upload: server reported Upload-Offset %q after PATCH, wanted %d", resp.Header.Get("Upload-Offset"), chunkEnd)
+			}
+
+			if fu.progressFunc != nil {
+				fu.progressFunc(chunkEnd, total)
+			}
+
+			offset = chunkEnd
+			if offset >= total {
+				return resp, nil
+			}
+			resp.Body.Close()
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("resumable upload: nothing to upload")
+}
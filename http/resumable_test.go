@@ -0,0 +1,286 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+const resumableTarget = "/resumable/"
+const resumableFlakyTarget = "/resumable-flaky/"
+const resumableConflictTarget = "/resumable-conflict/"
+
+//tusSession tracks the bytes received so far for one resumable test upload.
+type tusSession struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+var tusSessions = struct {
+	mu       sync.Mutex
+	sessions map[string]*tusSession
+	nextID   int
+}{sessions: make(map[string]*tusSession)}
+
+func resumableHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		tusSessions.mu.Lock()
+		tusSessions.nextID++
+		id := fmt.Sprintf("%d", tusSessions.nextID)
+		tusSessions.sessions[id] = &tusSession{}
+		tusSessions.mu.Unlock()
+
+		w.Header().Set("Location", resumableTarget+id)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodHead:
+		session := tusSessionFor(r.URL.Path)
+		if session == nil {
+			http.NotFound(w, r)
+			return
+		}
+		session.mu.Lock()
+		offset := len(session.data)
+		session.mu.Unlock()
+		w.Header().Set("Upload-Offset", fmt.Sprintf("%d", offset))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		session := tusSessionFor(r.URL.Path)
+		if session == nil {
+			http.NotFound(w, r)
+			return
+		}
+		chunk, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		session.mu.Lock()
+		session.data = append(session.data, chunk...)
+		offset := len(session.data)
+		session.mu.Unlock()
+		w.Header().Set("Upload-Offset", fmt.Sprintf("%d", offset))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func tusSessionFor(path string) *tusSession {
+	id := path[len(resumableTarget):]
+	tusSessions.mu.Lock()
+	defer tusSessions.mu.Unlock()
+	return tusSessions.sessions[id]
+}
+
+func TestResumableUpload(t *testing.T) {
+	fu := NewFormUploader()
+	fu.SetResumable(true)
+	fu.SetChunkSize(8)
+	fu.AddField("id", "Resumable upload")
+	fu.AddFiles(fileField, dataDir+"file01.txt")
+
+	url := serverHost + serverPort + resumableTarget
+	resp, err := fu.Post(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+}
+
+func TestResumableUploadReportsProgress(t *testing.T) {
+	fu := NewFormUploader()
+	fu.SetResumable(true)
+	fu.SetChunkSize(8)
+	fu.AddFiles(fileField, dataDir+"file01.txt")
+
+	var lastSent, lastTotal int64
+	fu.SetProgressFunc(func(bytesSent, totalBytes int64) {
+		lastSent = bytesSent
+		lastTotal = totalBytes
+	})
+
+	url := serverHost + serverPort + resumableTarget
+	resp, err := fu.Post(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if lastSent == 0 || lastSent != lastTotal {
+		t.Fatalf("progress not fully reported for resumable upload: sent=%d total=%d", lastSent, lastTotal)
+	}
+}
+
+//flakySession is like tusSession but fails a configurable number of PATCH
+//requests with a 500 before accepting the chunk, exercising the retry and
+//backoff path of uploadChunks.
+type flakySession struct {
+	mu            sync.Mutex
+	data          []byte
+	failRemaining int
+}
+
+var flakySessions = struct {
+	mu       sync.Mutex
+	sessions map[string]*flakySession
+	nextID   int
+}{sessions: make(map[string]*flakySession)}
+
+func resumableFlakyHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		flakySessions.mu.Lock()
+		flakySessions.nextID++
+		id := fmt.Sprintf("%d", flakySessions.nextID)
+		flakySessions.sessions[id] = &flakySession{failRemaining: 2}
+		flakySessions.mu.Unlock()
+
+		w.Header().Set("Location", resumableFlakyTarget+id)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodHead:
+		session := flakySessionFor(r.URL.Path)
+		if session == nil {
+			http.NotFound(w, r)
+			return
+		}
+		session.mu.Lock()
+		offset := len(session.data)
+		session.mu.Unlock()
+		w.Header().Set("Upload-Offset", fmt.Sprintf("%d", offset))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		session := flakySessionFor(r.URL.Path)
+		if session == nil {
+			http.NotFound(w, r)
+			return
+		}
+		chunk, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		session.mu.Lock()
+		if session.failRemaining > 0 {
+			session.failRemaining--
+			session.mu.Unlock()
+			http.Error(w, "simulated transient failure", http.StatusInternalServerError)
+			return
+		}
+		session.data = append(session.data, chunk...)
+		offset := len(session.data)
+		session.mu.Unlock()
+
+		w.Header().Set("Upload-Offset", fmt.Sprintf("%d", offset))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func flakySessionFor(path string) *flakySession {
+	id := path[len(resumableFlakyTarget):]
+	flakySessions.mu.Lock()
+	defer flakySessions.mu.Unlock()
+	return flakySessions.sessions[id]
+}
+
+//resumableConflictHandler always rejects PATCH chunks with 409, simulating
+//a non-retriable tus.io error the uploader must surface rather than treat
+//as successful.
+func resumableConflictHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		w.Header().Set("Location", resumableConflictTarget+"1")
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPatch:
+		http.Error(w, "offset conflict", http.StatusConflict)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func TestResumableUploadRetriesOnTransientFailure(t *testing.T) {
+	fu := NewFormUploader()
+	fu.SetResumable(true)
+	fu.SetChunkSize(8)
+	fu.SetMaxRetries(3)
+	fu.AddFiles(fileField, dataDir+"file01.txt")
+
+	url := serverHost + serverPort + resumableFlakyTarget
+	resp, err := fu.Post(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+}
+
+//TestResumableUploadResumeFromPartialSession simulates a process restart
+//partway through a resumable upload: one formUploader creates the session
+//and PATCHes only the first chunk, then a second, independent formUploader
+//with the same content re-added calls ResumeUpload to finish the rest,
+//exercising the streaming (not fully buffered) body path that ResumeUpload
+//relies on to replay and discard up to the reported offset.
+func TestResumableUploadResumeFromPartialSession(t *testing.T) {
+	fu1 := NewFormUploader().(*formUploader)
+	fu1.SetChunkSize(8)
+	if err := fu1.AddFiles(fileField, dataDir+"file01.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, contentType, total, err := fu1.openBodyStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	url := serverHost + serverPort + resumableTarget
+	sessionURL, err := fu1.createSession(context.Background(), http.DefaultClient, url, total, contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstChunk := make([]byte, fu1.chunkSize)
+	n, err := io.ReadFull(stream, firstChunk)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		t.Fatal(err)
+	}
+	resp, err := fu1.patchChunk(context.Background(), http.DefaultClient, sessionURL, 0, firstChunk[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	fu2 := NewFormUploader()
+	fu2.SetChunkSize(8)
+	if err := fu2.AddFiles(fileField, dataDir+"file01.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp2, err := fu2.ResumeUpload(sessionURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+}
+
+func TestResumableUploadGivesUpOnNonRetriableStatus(t *testing.T) {
+	fu := NewFormUploader()
+	fu.SetResumable(true)
+	fu.SetChunkSize(8)
+	fu.AddFiles(fileField, dataDir+"file01.txt")
+
+	url := serverHost + serverPort + resumableConflictTarget
+	resp, err := fu.Post(url)
+	if err == nil {
+		t.Fatal("expected error for a 409 response, got none")
+	}
+	if resp != nil {
+		t.Fatal("expected a nil response alongside the error")
+	}
+}
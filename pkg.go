@@ -6,3 +6,8 @@ import "github.com/ipsusila/gogo/http"
 func NewHTTPFormUploader() http.FormUploader {
 	return http.NewFormUploader()
 }
+
+//NewHTTPGraphQLUploader creates GraphQL multipart uploader instance.
+func NewHTTPGraphQLUploader() http.GraphQLUploader {
+	return http.NewGraphQLUploader()
+}